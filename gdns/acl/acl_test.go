@@ -0,0 +1,124 @@
+package acl
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func evalName(t *testing.T, e *Engine, qname string, now time.Time) Decision {
+	t.Helper()
+	ci := &ConnInfo{
+		ClientIP: net.ParseIP("10.0.0.1"),
+		Question: dns.Question{Name: qname, Qtype: dns.TypeA, Qclass: dns.ClassINET},
+	}
+	return e.Evaluate(ci, now)
+}
+
+func TestEnginePrioritySortsAscending(t *testing.T) {
+	e, err := New([]Rule{
+		{Priority: 10, Action: ActionBlock, QName: "example.com.", QNameMatch: MatchExact},
+		{Priority: 1, Action: ActionAllow, QName: "example.com.", QNameMatch: MatchExact},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := evalName(t, e, "example.com.", time.Now())
+	if !d.Matched || d.Rule.Action != ActionAllow {
+		t.Errorf("decision = %+v, want the lower-Priority (1) rule to win", d)
+	}
+}
+
+func TestRuleSuffixMatch(t *testing.T) {
+	e, err := New([]Rule{
+		{Priority: 0, Action: ActionBlock, QName: "ads.example.com.", QNameMatch: MatchSuffix},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		"ads.example.com.":         true,
+		"tracker.ads.example.com.": true,
+		"example.com.":             false,
+		"notads.example.com.":      false,
+	}
+	for qname, want := range cases {
+		d := evalName(t, e, qname, time.Now())
+		if d.Matched != want {
+			t.Errorf("qname %q: matched = %v, want %v", qname, d.Matched, want)
+		}
+	}
+}
+
+func TestRuleTimeOfDayWraparound(t *testing.T) {
+	e, err := New([]Rule{
+		{Priority: 0, Action: ActionBlock, QName: "example.com.", QNameMatch: MatchExact,
+			TimeOfDay: &TimeWindow{Start: "22:00", End: "06:00"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2026, 7, 26, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		hour, min int
+		want      bool
+	}{
+		{23, 0, true},  // well within the wrapped window
+		{1, 0, true},   // past midnight, still within the window
+		{6, 0, false},  // window end is exclusive
+		{12, 0, false}, // middle of the day, outside the window
+		{22, 0, true},  // window start is inclusive
+	}
+	for _, c := range cases {
+		now := time.Date(base.Year(), base.Month(), base.Day(), c.hour, c.min, 0, 0, time.UTC)
+		d := evalName(t, e, "example.com.", now)
+		if d.Matched != c.want {
+			t.Errorf("%02d:%02d: matched = %v, want %v", c.hour, c.min, d.Matched, c.want)
+		}
+	}
+}
+
+func TestLoadBlocklistFormats(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blocklist.txt")
+	content := "" +
+		"# comment\n" +
+		"! adguard comment\n" +
+		"0.0.0.0 hosts-style.example.com\n" +
+		"pihole-style.example.com\n" +
+		"||adguard-style.example.com^\n" +
+		"pihole-style.example.com\n" // duplicate, must be deduped
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadBlocklist(path, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		"hosts-style.example.com":   true,
+		"pihole-style.example.com":  true,
+		"adguard-style.example.com": true,
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("got %d rules, want %d (duplicates/comments must be skipped): %+v", len(rules), len(want), rules)
+	}
+	for _, r := range rules {
+		if !want[r.QName] {
+			t.Errorf("unexpected blocked host %q", r.QName)
+		}
+		if r.Priority != 42 || r.Action != ActionBlock || r.QNameMatch != MatchExact {
+			t.Errorf("rule for %q = %+v, want priority 42, action block, exact match", r.QName, r)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package acl
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// LoadBlocklist reads a hostlist file in Hosts ("0.0.0.0 ads.example.com"),
+// Pi-hole (one hostname per line), or AdGuard ("||ads.example.com^") format
+// and returns one ActionBlock Rule per entry, all sharing priority.
+func LoadBlocklist(path string, priority int) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []Rule
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		host := parseBlocklistLine(line)
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+
+		rules = append(rules, Rule{
+			Priority:   priority,
+			Action:     ActionBlock,
+			QName:      host,
+			QNameMatch: MatchExact,
+		})
+	}
+
+	return rules, scanner.Err()
+}
+
+// parseBlocklistLine extracts the blocked hostname from a single line of a
+// Hosts, Pi-hole, or AdGuard format blocklist, or "" if the line carries no
+// usable hostname.
+func parseBlocklistLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "||"):
+		// AdGuard: ||ads.example.com^ or ||ads.example.com^$important
+		host := strings.TrimPrefix(line, "||")
+		if i := strings.IndexAny(host, "^$/"); i >= 0 {
+			host = host[:i]
+		}
+		return strings.ToLower(host)
+
+	default:
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 0:
+			return ""
+		case 1:
+			// Pi-hole style: bare hostname per line.
+			return strings.ToLower(fields[0])
+		default:
+			// Hosts style: "0.0.0.0 ads.example.com [aliases...]"
+			return strings.ToLower(fields[1])
+		}
+	}
+}
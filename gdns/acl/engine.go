@@ -0,0 +1,101 @@
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v2"
+)
+
+// Engine evaluates an ordered list of Rules against incoming queries.
+type Engine struct {
+	rules []Rule
+}
+
+// Load reads an ACL file (YAML or JSON, chosen by extension) and returns an
+// Engine with its rules sorted by ascending Priority.
+func Load(path string) (*Engine, error) {
+	rules, err := LoadRules(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(rules)
+}
+
+// LoadRules reads an ACL file (YAML or JSON, chosen by extension) and
+// returns its uncompiled Rules, e.g. so a caller can merge them with rules
+// from other sources (like LoadBlocklist) before building an Engine with New.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if strings.HasSuffix(path, ".json") {
+		err = json.Unmarshal(data, &rules)
+	} else {
+		err = yaml.Unmarshal(data, &rules)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("acl: parsing %s: %v", path, err)
+	}
+
+	return rules, nil
+}
+
+// New builds an Engine from rules, compiling and sorting them.
+func New(rules []Rule) (*Engine, error) {
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("acl: rule %d: %v", i, err)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Priority < rules[j].Priority })
+	return &Engine{rules: rules}, nil
+}
+
+// ConnInfo carries the per-query facts an Engine needs to evaluate rules,
+// and is also the natural place to attach the resulting Decision for
+// logging/metrics.
+type ConnInfo struct {
+	ClientIP net.IP
+	Question dns.Question
+	Decision Decision
+}
+
+// Decision is the outcome of evaluating a query against an Engine: either a
+// matched Rule, or no match at all (Matched=false), meaning fall through to
+// the default upstream.
+type Decision struct {
+	Matched bool
+	Rule    Rule
+}
+
+// Evaluate returns the Decision for the given client/question/time, picking
+// the first (lowest-priority-number) rule that matches all of its
+// conditions.
+func (e *Engine) Evaluate(ci *ConnInfo, now time.Time) Decision {
+	qtypeName := dns.TypeToString[ci.Question.Qtype]
+
+	for _, r := range e.rules {
+		if r.matchesClient(ci.ClientIP) &&
+			r.matchesQName(ci.Question.Name) &&
+			r.matchesQType(qtypeName) &&
+			r.matchesTime(now) {
+			d := Decision{Matched: true, Rule: r}
+			ci.Decision = d
+			return d
+		}
+	}
+
+	d := Decision{Matched: false}
+	ci.Decision = d
+	return d
+}
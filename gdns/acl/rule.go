@@ -0,0 +1,168 @@
+// Package acl implements an ordered-rule policy engine for gdns.Handler:
+// each incoming query is matched against client IP/CIDR, qname, qtype and
+// time-of-day conditions, with the first (lowest priority number) matching
+// rule deciding whether the query is blocked, rewritten, routed to a
+// specific named upstream, or explicitly allowed through to the default
+// upstream.
+package acl
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Action is what to do with a query that matches a Rule.
+type Action string
+
+const (
+	// ActionBlock answers with NXDOMAIN (or REFUSED, per Rule.Refuse).
+	ActionBlock Action = "block"
+	// ActionRewrite answers with Rule.RewriteA/RewriteAAAA/RewriteCNAME.
+	ActionRewrite Action = "rewrite"
+	// ActionRoute forwards the query to the named upstream Rule.Upstream.
+	ActionRoute Action = "route"
+	// ActionAllow forwards the query to the default upstream, stopping
+	// further rule evaluation.
+	ActionAllow Action = "allow"
+)
+
+// QNameMatch describes how Rule.QName is compared against the query name.
+type QNameMatch string
+
+const (
+	// MatchExact requires an exact (case-insensitive) match.
+	MatchExact QNameMatch = "exact"
+	// MatchSuffix matches the query name or any subdomain of it.
+	MatchSuffix QNameMatch = "suffix"
+	// MatchRegex treats QName as a regular expression.
+	MatchRegex QNameMatch = "regex"
+)
+
+// TimeWindow restricts a Rule to a daily wall-clock window, e.g. 22:00-06:00
+// for "only at night".
+type TimeWindow struct {
+	Start string `json:"start" yaml:"start"` // "HH:MM"
+	End   string `json:"end" yaml:"end"`     // "HH:MM"
+}
+
+// Rule is a single ACL entry.
+type Rule struct {
+	Priority int    `json:"priority" yaml:"priority"`
+	Action   Action `json:"action" yaml:"action"`
+
+	ClientCIDRs []string `json:"clients,omitempty" yaml:"clients,omitempty"`
+
+	QName      string     `json:"qname,omitempty" yaml:"qname,omitempty"`
+	QNameMatch QNameMatch `json:"qname_match,omitempty" yaml:"qname_match,omitempty"`
+
+	QTypes []string `json:"qtypes,omitempty" yaml:"qtypes,omitempty"`
+
+	TimeOfDay *TimeWindow `json:"time_of_day,omitempty" yaml:"time_of_day,omitempty"`
+
+	// Refuse makes ActionBlock answer REFUSED instead of NXDOMAIN.
+	Refuse bool `json:"refuse,omitempty" yaml:"refuse,omitempty"`
+
+	// RewriteA/RewriteAAAA/RewriteCNAME are used by ActionRewrite.
+	RewriteA     string `json:"rewrite_a,omitempty" yaml:"rewrite_a,omitempty"`
+	RewriteAAAA  string `json:"rewrite_aaaa,omitempty" yaml:"rewrite_aaaa,omitempty"`
+	RewriteCNAME string `json:"rewrite_cname,omitempty" yaml:"rewrite_cname,omitempty"`
+
+	// Upstream names the provider to use for ActionRoute, matching the
+	// name a Provider reports via Provider.Name() or an alias configured
+	// by the caller.
+	Upstream string `json:"upstream,omitempty" yaml:"upstream,omitempty"`
+
+	cidrs []*net.IPNet
+	re    *regexp.Regexp
+}
+
+func (r *Rule) compile() error {
+	for _, c := range r.ClientCIDRs {
+		if !strings.Contains(c, "/") {
+			c += "/32"
+			if strings.Contains(c, ":") {
+				c = strings.TrimSuffix(c, "/32") + "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return err
+		}
+		r.cidrs = append(r.cidrs, n)
+	}
+
+	if r.QNameMatch == MatchRegex && r.QName != "" {
+		re, err := regexp.Compile(r.QName)
+		if err != nil {
+			return err
+		}
+		r.re = re
+	}
+
+	return nil
+}
+
+func (r *Rule) matchesClient(ip net.IP) bool {
+	if len(r.cidrs) == 0 {
+		return true
+	}
+	for _, n := range r.cidrs {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesQName(qname string) bool {
+	if r.QName == "" {
+		return true
+	}
+	qname = strings.ToLower(qname)
+	want := strings.ToLower(r.QName)
+
+	switch r.QNameMatch {
+	case MatchRegex:
+		return r.re != nil && r.re.MatchString(qname)
+	case MatchSuffix:
+		want = strings.TrimSuffix(want, ".") + "."
+		return qname == want || strings.HasSuffix(qname, "."+want)
+	default: // MatchExact
+		return strings.TrimSuffix(qname, ".") == strings.TrimSuffix(want, ".")
+	}
+}
+
+func (r *Rule) matchesQType(qtypeName string) bool {
+	if len(r.QTypes) == 0 {
+		return true
+	}
+	for _, t := range r.QTypes {
+		if strings.EqualFold(t, qtypeName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesTime(now time.Time) bool {
+	if r.TimeOfDay == nil {
+		return true
+	}
+	start, err1 := time.Parse("15:04", r.TimeOfDay.Start)
+	end, err2 := time.Parse("15:04", r.TimeOfDay.End)
+	if err1 != nil || err2 != nil {
+		return true
+	}
+
+	cur := now.Hour()*60 + now.Minute()
+	s := start.Hour()*60 + start.Minute()
+	e := end.Hour()*60 + end.Minute()
+
+	if s <= e {
+		return cur >= s && cur < e
+	}
+	// window wraps past midnight, e.g. 22:00-06:00
+	return cur >= s || cur < e
+}
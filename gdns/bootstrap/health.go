@@ -0,0 +1,27 @@
+package bootstrap
+
+import (
+	"net"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// healthCheckTimeout bounds a single candidate's TCP dial health check.
+const healthCheckTimeout = 3 * time.Second
+
+// healthCheck performs a lightweight TCP dial against ip:443 and updates
+// pool accordingly. A real HTTP/2 PING frame would be a more precise check
+// but a successful TLS-port TCP handshake is a good enough liveness signal
+// and avoids pulling in a full HTTP client here.
+func healthCheck(pool *Pool, ip net.IP) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), "443"), healthCheckTimeout)
+	if err != nil {
+		glog.V(2).Infof("bootstrap: %s unhealthy: %v", ip, err)
+		pool.MarkUnhealthy(ip)
+		return
+	}
+	conn.Close()
+	pool.MarkHealthy(ip, time.Since(start))
+}
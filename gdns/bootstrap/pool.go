@@ -0,0 +1,107 @@
+// Package bootstrap keeps a periodically refreshed, health-checked pool of
+// candidate IPs for a DoH endpoint hostname, so GDNSProvider's HTTP
+// transport can dial the currently-best one instead of a static
+// --endpoint-ips list.
+package bootstrap
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// quarantineBackoff is how long Best skips a candidate IP after it was
+// marked unhealthy. Health checks still probe it on every refresh.
+const quarantineBackoff = 30 * time.Second
+
+type candidate struct {
+	ip            net.IP
+	healthy       bool
+	latency       time.Duration
+	quarantinedAt time.Time
+}
+
+// Pool tracks the set of candidate IPs for a hostname and their health.
+type Pool struct {
+	mu         sync.RWMutex
+	candidates map[string]*candidate
+}
+
+// NewPool returns an empty Pool.
+func NewPool() *Pool {
+	return &Pool{candidates: make(map[string]*candidate)}
+}
+
+// SetCandidates replaces the pool's candidate set with ips, preserving
+// health state for IPs that were already present.
+func (p *Pool) SetCandidates(ips []net.IP) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	next := make(map[string]*candidate, len(ips))
+	for _, ip := range ips {
+		key := ip.String()
+		if c, ok := p.candidates[key]; ok {
+			next[key] = c
+			continue
+		}
+		next[key] = &candidate{ip: ip, healthy: true}
+	}
+	p.candidates = next
+}
+
+// MarkHealthy records a successful health check (or successful request) for
+// ip, clearing any quarantine and recording latency.
+func (p *Pool) MarkHealthy(ip net.IP, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.candidates[ip.String()]; ok {
+		c.healthy = true
+		c.latency = latency
+		c.quarantinedAt = time.Time{}
+	}
+}
+
+// MarkUnhealthy quarantines ip for quarantineBackoff.
+func (p *Pool) MarkUnhealthy(ip net.IP) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.candidates[ip.String()]; ok {
+		c.healthy = false
+		c.quarantinedAt = time.Now()
+	}
+}
+
+// Best returns the lowest-latency non-quarantined candidate, or nil if the
+// pool is empty or every candidate is currently quarantined.
+func (p *Pool) Best() net.IP {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var best *candidate
+	for _, c := range p.candidates {
+		if !c.healthy && time.Since(c.quarantinedAt) < quarantineBackoff {
+			continue
+		}
+		if best == nil || c.latency < best.latency {
+			best = c
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.ip
+}
+
+// Candidates returns a snapshot of every candidate IP currently tracked,
+// quarantined or not, for debug dumps.
+func (p *Pool) Candidates() []net.IP {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ips := make([]net.IP, 0, len(p.candidates))
+	for _, c := range p.candidates {
+		ips = append(ips, c.ip)
+	}
+	return ips
+}
@@ -0,0 +1,82 @@
+package bootstrap
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/miekg/dns"
+)
+
+// Resolver periodically re-resolves Hostname through BootstrapServers (or
+// the system resolver, if none are configured) and keeps Pool's candidate
+// set up to date, health-checking each candidate as it goes.
+type Resolver struct {
+	Hostname         string
+	BootstrapServers []string // host:port, queried with plain DNS
+	Interval         time.Duration
+	Pool             *Pool
+}
+
+// Start resolves once synchronously and then launches a background
+// goroutine that re-resolves and health-checks every Interval.
+func (r *Resolver) Start() error {
+	if err := r.refresh(); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := r.refresh(); err != nil {
+				glog.Errorf("bootstrap: refresh of %s failed: %v", r.Hostname, err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *Resolver) refresh() error {
+	ips, err := r.lookup()
+	if err != nil {
+		return err
+	}
+	r.Pool.SetCandidates(ips)
+
+	for _, ip := range ips {
+		go healthCheck(r.Pool, ip)
+	}
+
+	glog.V(2).Infof("bootstrap: %s resolved to %+v", r.Hostname, ips)
+	return nil
+}
+
+func (r *Resolver) lookup() ([]net.IP, error) {
+	if len(r.BootstrapServers) == 0 {
+		return net.LookupIP(r.Hostname)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(r.Hostname), dns.TypeA)
+
+	c := new(dns.Client)
+	var lastErr error
+	for _, server := range r.BootstrapServers {
+		resp, _, err := c.Exchange(m, server)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var ips []net.IP
+		for _, rr := range resp.Answer {
+			if a, ok := rr.(*dns.A); ok {
+				ips = append(ips, a.A)
+			}
+		}
+		if len(ips) > 0 {
+			return ips, nil
+		}
+	}
+	return nil, fmt.Errorf("bootstrap: no bootstrap server resolved %s: %v", r.Hostname, lastErr)
+}
@@ -0,0 +1,203 @@
+// Package cache implements an in-memory, RFC-compliant response cache for
+// gdns.Handler: whole dns.Msg answers are stored keyed by (qname, qtype,
+// qclass, ECS subnet) with per-record TTLs decremented on the fly so a
+// cached answer always reports how much time is actually left.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Options configures a Cache.
+type Options struct {
+	// MaxEntries bounds the number of distinct cached questions; the least
+	// recently used entry is evicted once the bound is reached.
+	MaxEntries int
+
+	// MinTTL/MaxTTL clamp the TTL of stored (positive) answers.
+	MinTTL uint32
+	MaxTTL uint32
+
+	// NegTTLCap bounds the TTL used for negative (NXDOMAIN/NODATA) answers,
+	// which is otherwise taken from the SOA MINIMUM per RFC 2308.
+	NegTTLCap uint32
+}
+
+type entry struct {
+	key     Key
+	msg     *dns.Msg
+	stored  time.Time
+	expires time.Time
+}
+
+// Cache is an LRU cache of dns.Msg answers with RFC 2308 negative caching
+// and TTL decrementing. It is safe for concurrent use.
+type Cache struct {
+	opts Options
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[Key]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+// New returns a Cache configured by opts.
+func New(opts Options) *Cache {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 10000
+	}
+	return &Cache{
+		opts:  opts,
+		ll:    list.New(),
+		items: make(map[Key]*list.Element),
+	}
+}
+
+// Get returns a copy of the cached answer for req, with TTLs decremented by
+// the time elapsed since it was stored, or nil if there is no usable entry.
+func (c *Cache) Get(req *dns.Msg) *dns.Msg {
+	k := keyFor(req)
+
+	c.mu.Lock()
+	el, ok := c.items[k]
+	if !ok {
+		c.mu.Unlock()
+		atomic.AddUint64(&c.misses, 1)
+		return nil
+	}
+	ent := el.Value.(*entry)
+	remaining := time.Until(ent.expires)
+	if remaining <= 0 {
+		c.ll.Remove(el)
+		delete(c.items, k)
+		c.mu.Unlock()
+		atomic.AddUint64(&c.misses, 1)
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	msg := ent.msg.Copy()
+	age := time.Since(ent.stored)
+	c.mu.Unlock()
+
+	atomic.AddUint64(&c.hits, 1)
+	decrementTTL(msg, uint32(age/time.Second))
+	return msg
+}
+
+// Set stores resp as the answer for req, honoring per-record TTLs (a TTL=0
+// record makes the whole answer uncacheable, per the task) and RFC 2308
+// negative caching bounded by NegTTLCap.
+func (c *Cache) Set(req, resp *dns.Msg) {
+	if resp == nil || len(req.Question) == 0 {
+		return
+	}
+
+	ttl, ok := cacheTTL(resp, c.opts)
+	if !ok {
+		return
+	}
+
+	k := keyFor(req)
+	now := time.Now()
+	ent := &entry{key: k, msg: resp.Copy(), stored: now, expires: now.Add(time.Duration(ttl) * time.Second)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[k]; ok {
+		el.Value = ent
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[k] = c.ll.PushFront(ent)
+	for c.ll.Len() > c.opts.MaxEntries {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).key)
+	}
+}
+
+// Stats is a point-in-time snapshot of hit/miss counters, suitable for a
+// /cache/stats endpoint or a SIGUSR1 dump.
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// Stats returns the current hit/miss counters and entry count.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	n := c.ll.Len()
+	c.mu.Unlock()
+	return Stats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: n,
+	}
+}
+
+// cacheTTL computes the TTL to store resp under, or ok=false if resp must
+// not be cached at all.
+func cacheTTL(resp *dns.Msg, opts Options) (ttl uint32, ok bool) {
+	if len(resp.Answer) == 0 {
+		return negativeTTL(resp, opts)
+	}
+
+	min := ^uint32(0)
+	for _, rr := range resp.Answer {
+		if rr.Header().Ttl == 0 {
+			return 0, false
+		}
+		if rr.Header().Ttl < min {
+			min = rr.Header().Ttl
+		}
+	}
+
+	if opts.MinTTL > 0 && min < opts.MinTTL {
+		min = opts.MinTTL
+	}
+	if opts.MaxTTL > 0 && min > opts.MaxTTL {
+		min = opts.MaxTTL
+	}
+	return min, true
+}
+
+// negativeTTL implements RFC 2308 negative caching: the TTL of a
+// NXDOMAIN/NODATA answer is the MINIMUM field of the SOA record in the
+// authority section, capped by opts.NegTTLCap.
+func negativeTTL(resp *dns.Msg, opts Options) (uint32, bool) {
+	for _, rr := range resp.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			ttl := soa.Minttl
+			if opts.NegTTLCap > 0 && ttl > opts.NegTTLCap {
+				ttl = opts.NegTTLCap
+			}
+			if ttl == 0 {
+				return 0, false
+			}
+			return ttl, true
+		}
+	}
+	return 0, false
+}
+
+func decrementTTL(m *dns.Msg, age uint32) {
+	for _, rr := range m.Answer {
+		h := rr.Header()
+		if h.Ttl > age {
+			h.Ttl -= age
+		} else {
+			h.Ttl = 0
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func mustRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatalf("dns.NewRR(%q): %v", s, err)
+	}
+	return rr
+}
+
+func TestCacheTTLPositive(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.Answer = []dns.RR{
+		mustRR(t, "example.com. 300 IN A 1.2.3.4"),
+		mustRR(t, "example.com. 60 IN A 1.2.3.5"),
+	}
+
+	ttl, ok := cacheTTL(resp, Options{})
+	if !ok {
+		t.Fatal("expected cacheable response")
+	}
+	if ttl != 60 {
+		t.Errorf("ttl = %d, want 60 (the minimum of the answer RRs)", ttl)
+	}
+}
+
+func TestCacheTTLZeroIsUncacheable(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.Answer = []dns.RR{mustRR(t, "example.com. 0 IN A 1.2.3.4")}
+
+	if _, ok := cacheTTL(resp, Options{}); ok {
+		t.Error("a TTL=0 answer must not be cacheable")
+	}
+}
+
+func TestCacheTTLClampedByMinMax(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.Answer = []dns.RR{mustRR(t, "example.com. 10 IN A 1.2.3.4")}
+
+	ttl, ok := cacheTTL(resp, Options{MinTTL: 30, MaxTTL: 3600})
+	if !ok || ttl != 30 {
+		t.Errorf("ttl = %d, ok = %v, want 30, true (clamped up to MinTTL)", ttl, ok)
+	}
+
+	resp.Answer = []dns.RR{mustRR(t, "example.com. 7200 IN A 1.2.3.4")}
+	ttl, ok = cacheTTL(resp, Options{MinTTL: 30, MaxTTL: 3600})
+	if !ok || ttl != 3600 {
+		t.Errorf("ttl = %d, ok = %v, want 3600, true (clamped down to MaxTTL)", ttl, ok)
+	}
+}
+
+func TestNegativeTTLUsesSOAMinttl(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.Ns = []dns.RR{mustRR(t, "example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 3600 600 86400 120")}
+
+	ttl, ok := negativeTTL(resp, Options{})
+	if !ok {
+		t.Fatal("expected negative answer with an SOA to be cacheable")
+	}
+	if ttl != 120 {
+		t.Errorf("ttl = %d, want 120 (the SOA Minttl field)", ttl)
+	}
+}
+
+func TestNegativeTTLCappedByNegTTLCap(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.Ns = []dns.RR{mustRR(t, "example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 3600 600 86400 7200")}
+
+	ttl, ok := negativeTTL(resp, Options{NegTTLCap: 300})
+	if !ok || ttl != 300 {
+		t.Errorf("ttl = %d, ok = %v, want 300, true (capped by NegTTLCap)", ttl, ok)
+	}
+}
+
+func TestNegativeTTLWithoutSOAIsUncacheable(t *testing.T) {
+	resp := new(dns.Msg)
+	if _, ok := negativeTTL(resp, Options{}); ok {
+		t.Error("a negative answer with no SOA in the authority section must not be cacheable")
+	}
+}
+
+func TestCacheGetSetRoundTrip(t *testing.T) {
+	c := New(Options{MaxEntries: 10})
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Answer = []dns.RR{mustRR(t, "example.com. 300 IN A 1.2.3.4")}
+
+	if got := c.Get(req); got != nil {
+		t.Fatal("expected a miss before Set")
+	}
+
+	c.Set(req, resp)
+
+	got := c.Get(req)
+	if got == nil {
+		t.Fatal("expected a hit after Set")
+	}
+	if len(got.Answer) != 1 || got.Answer[0].Header().Ttl > 300 {
+		t.Errorf("unexpected cached answer: %+v", got.Answer)
+	}
+}
+
+func TestCacheEvictsLRU(t *testing.T) {
+	c := New(Options{MaxEntries: 1})
+
+	req1 := new(dns.Msg)
+	req1.SetQuestion("a.example.com.", dns.TypeA)
+	resp1 := new(dns.Msg)
+	resp1.SetReply(req1)
+	resp1.Answer = []dns.RR{mustRR(t, "a.example.com. 300 IN A 1.2.3.4")}
+	c.Set(req1, resp1)
+
+	req2 := new(dns.Msg)
+	req2.SetQuestion("b.example.com.", dns.TypeA)
+	resp2 := new(dns.Msg)
+	resp2.SetReply(req2)
+	resp2.Answer = []dns.RR{mustRR(t, "b.example.com. 300 IN A 5.6.7.8")}
+	c.Set(req2, resp2)
+
+	if c.Get(req1) != nil {
+		t.Error("expected the first entry to be evicted once MaxEntries was exceeded")
+	}
+	if c.Get(req2) == nil {
+		t.Error("expected the most recently set entry to still be cached")
+	}
+}
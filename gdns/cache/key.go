@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Key identifies a cacheable answer. Two queries that differ only in casing
+// or in EDNS options other than client-subnet hash to the same Key.
+type Key struct {
+	Name   string
+	Qtype  uint16
+	Qclass uint16
+	ECS    string
+}
+
+// keyFor derives the Key for req, lowercasing the qname per RFC 4343 and
+// pulling the client-subnet address (if any) out of the OPT record so
+// answers are not shared across subnets that might get different results.
+func keyFor(req *dns.Msg) Key {
+	if len(req.Question) == 0 {
+		return Key{}
+	}
+	q := req.Question[0]
+
+	k := Key{
+		Name:   strings.ToLower(q.Name),
+		Qtype:  q.Qtype,
+		Qclass: q.Qclass,
+	}
+
+	if opt := req.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if subnet, ok := o.(*dns.EDNS0_SUBNET); ok {
+				k.ECS = subnet.Address.String() + "/" + strconv.Itoa(int(subnet.SourceNetmask))
+			}
+		}
+	}
+
+	return k
+}
+
+// SingleflightKey returns a string uniquely identifying the same Key as
+// keyFor, suitable for coalescing identical concurrent queries (e.g. via
+// golang.org/x/sync/singleflight) without exposing the Key type itself.
+func SingleflightKey(req *dns.Msg) string {
+	k := keyFor(req)
+	return k.Name + "|" + strconv.Itoa(int(k.Qtype)) + "|" + strconv.Itoa(int(k.Qclass)) + "|" + k.ECS
+}
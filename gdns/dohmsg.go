@@ -0,0 +1,78 @@
+package gdns
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DoHMsgProvider implements the generic RFC 8484 DoH transport: queries and
+// answers are the raw wire-format dns.Msg, POSTed/GETed as
+// application/dns-message. Unlike GDNSProvider and CloudflareProvider it
+// works against any RFC 8484 compliant resolver, not just Google/Cloudflare's
+// JSON APIs.
+type DoHMsgProvider struct {
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewDoHMsgProvider builds a Provider that speaks RFC 8484
+// application/dns-message against rawurl.
+func NewDoHMsgProvider(rawurl string, opts *GDNSOptions) (*DoHMsgProvider, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse endpoint %q: %v", rawurl, err)
+	}
+
+	return &DoHMsgProvider{
+		endpoint: u,
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: !opts.Secure},
+			},
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// Name implements Provider.
+func (p *DoHMsgProvider) Name() string { return p.endpoint.String() }
+
+// Query implements Provider by POSTing the raw query message and parsing the
+// raw answer message out of the response body.
+func (p *DoHMsgProvider) Query(req *dns.Msg) (*dns.Msg, error) {
+	wire, err := req.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest("POST", p.endpoint.String(), bytes.NewReader(wire))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("content-type", "application/dns-message")
+	httpReq.Header.Set("accept", "application/dns-message")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m := new(dns.Msg)
+	if err := m.Unpack(body); err != nil {
+		return nil, fmt.Errorf("gdns: unpacking dns-message response: %v", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,52 @@
+package dohserver
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// capturingWriter adapts an HTTP request to dns.ResponseWriter, capturing
+// the *dns.Msg a gdns.Handler writes instead of putting it on a network
+// connection, so DoH requests can run through the same Handle dispatch
+// (ACL/cache/metrics/querylog) as the UDP/TCP/DoQ listeners.
+type capturingWriter struct {
+	remoteAddr net.Addr
+	msg        *dns.Msg
+}
+
+// newCapturingWriter builds a capturingWriter reporting remoteAddr (an
+// http.Request.RemoteAddr, "host:port") as the client address, e.g. for ACL
+// rules that match on client IP.
+func newCapturingWriter(remoteAddr string) *capturingWriter {
+	host, portStr, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+		portStr = "0"
+	}
+	port, _ := strconv.Atoi(portStr)
+	return &capturingWriter{remoteAddr: &net.TCPAddr{IP: net.ParseIP(host), Port: port}}
+}
+
+func (w *capturingWriter) LocalAddr() net.Addr  { return w.remoteAddr }
+func (w *capturingWriter) RemoteAddr() net.Addr { return w.remoteAddr }
+
+func (w *capturingWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(b); err != nil {
+		return 0, err
+	}
+	w.msg = m
+	return len(b), nil
+}
+
+func (w *capturingWriter) Close() error        { return nil }
+func (w *capturingWriter) TsigStatus() error   { return nil }
+func (w *capturingWriter) TsigTimersOnly(bool) {}
+func (w *capturingWriter) Hijack()             {}
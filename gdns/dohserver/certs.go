@@ -0,0 +1,93 @@
+package dohserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/http2"
+)
+
+// reloadableCert serves the currently loaded certificate to the TLS stack,
+// and is swapped out wholesale on SIGHUP so a renewed cert/key pair can be
+// picked up without dropping the listener.
+type reloadableCert struct {
+	certFile, keyFile string
+	current           atomic.Value // holds *tls.Certificate
+}
+
+func newReloadableCert(certFile, keyFile string) (*reloadableCert, error) {
+	rc := &reloadableCert{certFile: certFile, keyFile: keyFile}
+	if err := rc.reload(); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+func (rc *reloadableCert) reload() error {
+	cert, err := tls.LoadX509KeyPair(rc.certFile, rc.keyFile)
+	if err != nil {
+		return fmt.Errorf("dohserver: loading cert/key: %v", err)
+	}
+	rc.current.Store(&cert)
+	return nil
+}
+
+func (rc *reloadableCert) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return rc.current.Load().(*tls.Certificate), nil
+}
+
+// watchSIGHUP reloads the certificate every time the process receives
+// SIGHUP, e.g. after `certbot renew`.
+func (rc *reloadableCert) watchSIGHUP() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		for range sig {
+			if err := rc.reload(); err != nil {
+				glog.Errorf("dohserver: cert reload failed: %v", err)
+			} else {
+				glog.V(1).Infof("dohserver: reloaded TLS certificate from %s", rc.certFile)
+			}
+		}
+	}()
+}
+
+// ListenAndServeTLS runs an HTTP/2-enabled HTTPS server for s on addr using
+// certFile/keyFile, reloading the certificate on SIGHUP.
+func (s *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
+	rc, err := newReloadableCert(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	rc.watchSIGHUP()
+
+	tlsConfig := &tls.Config{
+		GetCertificate: rc.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   s.Handler(),
+		TLSConfig: tlsConfig,
+	}
+	if err := http2.ConfigureServer(server, nil); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	tlsLn := tls.NewListener(ln, tlsConfig)
+
+	glog.V(1).Infof("dohserver: serving DoH on %s", addr)
+	return server.Serve(tlsLn)
+}
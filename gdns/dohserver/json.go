@@ -0,0 +1,71 @@
+package dohserver
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// jsonResponse mirrors Google's / Cloudflare's application/dns-json shape.
+type jsonResponse struct {
+	Status int      `json:"Status"`
+	Answer []jsonRR `json:"Answer,omitempty"`
+}
+
+type jsonRR struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32 `json:"TTL"`
+	Data string `json:"data"`
+}
+
+func toJSONResponse(m *dns.Msg) jsonResponse {
+	jr := jsonResponse{Status: m.Rcode}
+	for _, rr := range m.Answer {
+		h := rr.Header()
+		jr.Answer = append(jr.Answer, jsonRR{
+			Name: h.Name,
+			Type: h.Rrtype,
+			TTL:  h.Ttl,
+			Data: rrData(rr),
+		})
+	}
+	return jr
+}
+
+// rrData extracts just the RDATA portion of rr's string form, which is what
+// the JSON API puts in "data".
+func rrData(rr dns.RR) string {
+	full := rr.String()
+	h := rr.Header()
+	prefix := fmt.Sprintf("%s\t%d\t%s\t%s\t", h.Name, h.Ttl, dns.ClassToString[h.Class], dns.TypeToString[h.Rrtype])
+	if len(full) > len(prefix) {
+		return full[len(prefix):]
+	}
+	return full
+}
+
+// dnsParamToWire decodes the base64url-encoded "dns" query parameter used by
+// RFC 8484 GET requests.
+func dnsParamToWire(param string) ([]byte, error) {
+	if param == "" {
+		return nil, fmt.Errorf("missing dns parameter")
+	}
+	return base64.RawURLEncoding.DecodeString(param)
+}
+
+// cacheControl derives a "max-age=N" header from the smallest TTL in the
+// answer section, per the RFC 8484 recommendation.
+func cacheControl(m *dns.Msg) string {
+	if len(m.Answer) == 0 {
+		return "max-age=0"
+	}
+	min := m.Answer[0].Header().Ttl
+	for _, rr := range m.Answer[1:] {
+		if t := rr.Header().Ttl; t < min {
+			min = t
+		}
+	}
+	return fmt.Sprintf("max-age=%d", min)
+}
@@ -0,0 +1,104 @@
+// Package dohserver implements the reverse side of this proxy: an HTTPS
+// server that looks like Google's ("/resolve") and RFC 8484's
+// ("/dns-query") DoH endpoints to its own clients, forwarding every query
+// through a configurable backend *gdns.Handler so DoH traffic gets the same
+// ACL/cache/metrics treatment as the UDP/TCP/DoQ listeners.
+package dohserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/chenhw2/google-https-dns/gdns"
+	"github.com/miekg/dns"
+)
+
+// Server answers DoH requests by running them through Backend.
+type Server struct {
+	Backend *gdns.Handler
+}
+
+// New returns a Server that forwards to backend.
+func New(backend *gdns.Handler) *Server {
+	return &Server{Backend: backend}
+}
+
+// Handler returns the http.Handler implementing both DoH flavours.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/resolve", s.serveJSON)
+	mux.HandleFunc("/dns-query", s.serveMessage)
+	return mux
+}
+
+// serveJSON implements the Google-compatible application/dns-json endpoint.
+func (s *Server) serveJSON(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing name parameter", http.StatusBadRequest)
+		return
+	}
+	qtype := dns.TypeA
+	if t := r.URL.Query().Get("type"); t != "" {
+		if v, err := strconv.Atoi(t); err == nil {
+			qtype = uint16(v)
+		} else if v, ok := dns.StringToType[t]; ok {
+			qtype = v
+		}
+	}
+
+	req := new(dns.Msg)
+	req.SetQuestion(dns.Fqdn(name), qtype)
+
+	rw := newCapturingWriter(r.RemoteAddr)
+	s.Backend.Handle(rw, req)
+	resp := rw.msg
+
+	w.Header().Set("content-type", "application/dns-json")
+	w.Header().Set("cache-control", cacheControl(resp))
+	json.NewEncoder(w).Encode(toJSONResponse(resp))
+}
+
+// serveMessage implements the RFC 8484 application/dns-message endpoint,
+// accepting either a GET with a base64url "dns" parameter or a raw POST body.
+func (s *Server) serveMessage(w http.ResponseWriter, r *http.Request) {
+	var wire []byte
+	var err error
+
+	switch r.Method {
+	case http.MethodGet:
+		wire, err = dnsParamToWire(r.URL.Query().Get("dns"))
+	case http.MethodPost:
+		wire, err = ioutil.ReadAll(r.Body)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := new(dns.Msg)
+	if err := req.Unpack(wire); err != nil {
+		http.Error(w, fmt.Sprintf("malformed dns-message: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	rw := newCapturingWriter(r.RemoteAddr)
+	s.Backend.Handle(rw, req)
+	resp := rw.msg
+
+	out, err := resp.Pack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/dns-message")
+	w.Header().Set("cache-control", cacheControl(resp))
+	w.Write(out)
+}
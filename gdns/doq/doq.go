@@ -0,0 +1,55 @@
+// Package doq implements DNS-over-QUIC (RFC 9250): each query is sent on
+// its own QUIC stream, framed with a 2-byte big-endian length prefix, ALPN
+// "doq". The package provides both an upstream Provider and a listener that
+// can front the same dns.Handler used by the UDP/TCP servers.
+package doq
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/miekg/dns"
+)
+
+// ALPN is the application protocol negotiated for DoQ connections.
+const ALPN = "doq"
+
+// readMsg reads one RFC 9250 length-prefixed dns.Msg from r.
+func readMsg(r io.Reader) (*dns.Msg, error) {
+	var lenBuf [2]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint16(lenBuf[:])
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return nil, fmt.Errorf("doq: unpacking message: %v", err)
+	}
+	return m, nil
+}
+
+// writeMsg writes m to w using the RFC 9250 length-prefixed framing.
+func writeMsg(w io.Writer, m *dns.Msg) error {
+	wire, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	if len(wire) > 0xFFFF {
+		return fmt.Errorf("doq: message too large (%d bytes)", len(wire))
+	}
+
+	var lenBuf [2]byte
+	binary.BigEndian.PutUint16(lenBuf[:], uint16(len(wire)))
+	if _, err := w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(wire)
+	return err
+}
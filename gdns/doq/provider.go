@@ -0,0 +1,94 @@
+package doq
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// Provider forwards queries to a DoQ resolver (e.g. AdGuard, NextDNS) over a
+// pooled QUIC connection, opening one stream per query as RFC 9250
+// requires.
+type Provider struct {
+	addr      string
+	tlsConfig *tls.Config
+
+	mu    sync.Mutex
+	sess  quic.Connection
+	dirty bool
+}
+
+// NewProvider builds a Provider that forwards queries to addr (host:port,
+// conventionally :853).
+func NewProvider(addr string, insecure bool) *Provider {
+	return &Provider{
+		addr: addr,
+		tlsConfig: &tls.Config{
+			NextProtos:         []string{ALPN},
+			InsecureSkipVerify: insecure,
+		},
+	}
+}
+
+// Name implements gdns.Provider.
+func (p *Provider) Name() string { return "quic://" + p.addr }
+
+// Query implements gdns.Provider by opening a new QUIC stream on the pooled
+// session and exchanging one length-prefixed message each way.
+func (p *Provider) Query(req *dns.Msg) (*dns.Msg, error) {
+	sess, err := p.session()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := sess.OpenStreamSync(ctx)
+	if err != nil {
+		p.invalidate()
+		return nil, fmt.Errorf("doq: opening stream: %v", err)
+	}
+	defer stream.Close()
+
+	if err := writeMsg(stream, req); err != nil {
+		return nil, err
+	}
+	// RFC 9250: the client MUST send a FIN after its query.
+	stream.Close()
+
+	resp, err := readMsg(stream)
+	if err != nil {
+		return nil, err
+	}
+	resp.Id = req.Id
+	return resp, nil
+}
+
+func (p *Provider) session() (quic.Connection, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sess != nil && !p.dirty {
+		return p.sess, nil
+	}
+
+	sess, err := quic.DialAddr(context.Background(), p.addr, p.tlsConfig, nil)
+	if err != nil {
+		return nil, fmt.Errorf("doq: dialing %s: %v", p.addr, err)
+	}
+	p.sess = sess
+	p.dirty = false
+	return sess, nil
+}
+
+func (p *Provider) invalidate() {
+	p.mu.Lock()
+	p.dirty = true
+	p.mu.Unlock()
+}
@@ -0,0 +1,73 @@
+package doq
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/golang/glog"
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// ListenAndServe runs a DoQ listener on addr, dispatching every query on
+// every accepted stream to handler.Handle, just like the UDP/TCP servers.
+func ListenAndServe(addr string, tlsConfig *tls.Config, handler dns.Handler) error {
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{ALPN}
+
+	listener, err := quic.ListenAddr(addr, tlsConfig, nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		sess, err := listener.Accept(context.Background())
+		if err != nil {
+			return err
+		}
+		go serveSession(sess, handler)
+	}
+}
+
+func serveSession(sess quic.Connection, handler dns.Handler) {
+	for {
+		stream, err := sess.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		go serveStream(sess, stream, handler)
+	}
+}
+
+func serveStream(sess quic.Connection, stream quic.Stream, handler dns.Handler) {
+	defer stream.Close()
+
+	req, err := readMsg(stream)
+	if err != nil {
+		glog.Errorf("doq: reading query: %v", err)
+		return
+	}
+
+	rw := &responseWriter{sess: sess, stream: stream}
+	handler.ServeDNS(rw, req)
+}
+
+// responseWriter adapts a single QUIC stream to dns.ResponseWriter so the
+// existing gdns.Handler.Handle can answer DoQ queries unmodified.
+type responseWriter struct {
+	sess   quic.Connection
+	stream quic.Stream
+}
+
+func (w *responseWriter) LocalAddr() net.Addr  { return w.sess.LocalAddr() }
+func (w *responseWriter) RemoteAddr() net.Addr { return w.sess.RemoteAddr() }
+
+func (w *responseWriter) WriteMsg(m *dns.Msg) error { return writeMsg(w.stream, m) }
+func (w *responseWriter) Write(b []byte) (int, error) {
+	return w.stream.Write(b)
+}
+func (w *responseWriter) Close() error        { return w.stream.Close() }
+func (w *responseWriter) TsigStatus() error   { return nil }
+func (w *responseWriter) TsigTimersOnly(bool) {}
+func (w *responseWriter) Hijack()             {}
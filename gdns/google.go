@@ -0,0 +1,165 @@
+package gdns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// GDNSProvider talks to Google's JSON DoH API (https://dns.google.com/resolve).
+type GDNSProvider struct {
+	endpoint *url.URL
+	opts     *GDNSOptions
+	client   *http.Client
+}
+
+// NewGDNSProvider builds a Provider that queries the Google DNS-over-HTTPS
+// JSON endpoint at rawurl.
+func NewGDNSProvider(rawurl string, opts *GDNSOptions) (*GDNSProvider, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse endpoint %q: %v", rawurl, err)
+	}
+
+	p := &GDNSProvider{
+		endpoint: u,
+		opts:     opts,
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: !opts.Secure},
+	}
+	if opts.Bootstrap != nil || len(opts.EndpointIPs) > 0 {
+		transport.DialContext = p.dialWithEndpointIPs
+	}
+	p.client = &http.Client{Transport: transport, Timeout: 10 * time.Second}
+
+	return p, nil
+}
+
+func (p *GDNSProvider) dialWithEndpointIPs(ctx context.Context, network, addr string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		port = "443"
+	}
+
+	if p.opts.Bootstrap != nil {
+		return p.dialBootstrapPool(network, port)
+	}
+
+	var lastErr error
+	for _, ip := range p.opts.EndpointIPs {
+		conn, err := net.DialTimeout(network, net.JoinHostPort(ip.String(), port), 5*time.Second)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// dialBootstrapPool dials the bootstrap pool's current best candidate. On
+// failure it quarantines that candidate (so Best won't pick it again until
+// the quarantine backoff expires) and retries the next-best one, so a
+// single bad pick fails over within this request instead of failing every
+// query until the next periodic health check.
+func (p *GDNSProvider) dialBootstrapPool(network, port string) (net.Conn, error) {
+	pool := p.opts.Bootstrap
+	tried := make(map[string]bool)
+
+	var lastErr error
+	for {
+		ip := pool.Best()
+		if ip == nil || tried[ip.String()] {
+			break
+		}
+		tried[ip.String()] = true
+
+		conn, err := net.DialTimeout(network, net.JoinHostPort(ip.String(), port), 5*time.Second)
+		if err == nil {
+			return conn, nil
+		}
+		pool.MarkUnhealthy(ip)
+		lastErr = err
+	}
+
+	if lastErr != nil {
+		return nil, fmt.Errorf("gdns: bootstrap: all candidates failed, last error: %v", lastErr)
+	}
+	return nil, fmt.Errorf("gdns: bootstrap: no healthy endpoint IP available")
+}
+
+// Name implements Provider.
+func (p *GDNSProvider) Name() string { return "google" }
+
+// Query implements Provider by issuing a GET against the Google JSON API and
+// translating the JSON answer back into a dns.Msg.
+func (p *GDNSProvider) Query(req *dns.Msg) (*dns.Msg, error) {
+	if len(req.Question) == 0 {
+		return nil, fmt.Errorf("gdns: empty question section")
+	}
+	q := req.Question[0]
+
+	query := url.Values{}
+	query.Set("name", q.Name)
+	query.Set("type", fmt.Sprintf("%d", q.Qtype))
+	if p.opts.EDNS != "" {
+		query.Set("edns_client_subnet", p.opts.EDNS)
+	}
+	if p.opts.Pad {
+		// Pad so that name plus padding is always 256 characters long.
+		if n := 256 - len(q.Name); n > 0 {
+			query.Set("random_padding", strings.Repeat("X", n))
+		}
+	}
+
+	u := *p.endpoint
+	u.RawQuery = query.Encode()
+
+	resp, err := p.client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var jr jsonDNSResponse
+	if err := json.NewDecoder(resp.Body).Decode(&jr); err != nil {
+		return nil, fmt.Errorf("gdns: decoding response: %v", err)
+	}
+
+	return jr.toMsg(req)
+}
+
+// jsonDNSResponse mirrors the shape of Google's / Cloudflare's JSON DoH API.
+type jsonDNSResponse struct {
+	Status int `json:"Status"`
+	Answer []struct {
+		Name string `json:"name"`
+		Type uint16 `json:"type"`
+		TTL  uint32 `json:"TTL"`
+		Data string `json:"data"`
+	} `json:"Answer"`
+}
+
+func (jr *jsonDNSResponse) toMsg(req *dns.Msg) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Rcode = jr.Status
+
+	for _, a := range jr.Answer {
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", a.Name, a.TTL, dns.TypeToString[a.Type], a.Data))
+		if err != nil {
+			continue
+		}
+		m.Answer = append(m.Answer, rr)
+	}
+	return m, nil
+}
@@ -0,0 +1,298 @@
+package gdns
+
+import (
+	"net"
+	"time"
+
+	"github.com/chenhw2/google-https-dns/gdns/acl"
+	"github.com/chenhw2/google-https-dns/gdns/cache"
+	"github.com/chenhw2/google-https-dns/gdns/metrics"
+	"github.com/golang/glog"
+	"github.com/miekg/dns"
+	"golang.org/x/sync/singleflight"
+)
+
+// HandlerOptions configures Handler behaviour.
+type HandlerOptions struct {
+	// ACL, if set, is consulted before every query is forwarded upstream.
+	ACL *acl.Engine
+
+	// Upstreams resolves the name used by an acl.ActionRoute rule's
+	// Upstream field to the Provider it should be sent to. Providers not
+	// listed here cannot be the target of a route rule.
+	Upstreams map[string]Provider
+
+	// Metrics, if set, records Prometheus counters/histograms for every
+	// query.
+	Metrics *metrics.Recorder
+
+	// QueryLog, if set, appends one structured JSON record per query.
+	QueryLog *metrics.QueryLogger
+
+	// Cache, if set, is checked before (and populated after) every
+	// non-ACL-short-circuited query, so cache hits still go through the
+	// ACL/Metrics/QueryLog bookkeeping in Handle instead of bypassing it.
+	Cache *cache.Cache
+}
+
+// Handler dispatches incoming dns.Msg queries to a Provider and writes the
+// upstream answer back to the client. If opts.Cache is set, it sits inside
+// the same dispatch Handle uses for every other query, so a cache hit still
+// runs through ACL evaluation and is still recorded to Metrics/QueryLog.
+type Handler struct {
+	provider Provider
+	opts     *HandlerOptions
+	group    singleflight.Group
+}
+
+// NewHandler returns a Handler that forwards every query to provider.
+func NewHandler(provider Provider, opts *HandlerOptions) *Handler {
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	return &Handler{provider: provider, opts: opts}
+}
+
+// Handle implements dns.HandlerFunc.
+func (h *Handler) Handle(w dns.ResponseWriter, r *dns.Msg) {
+	start := time.Now()
+	if h.opts.Metrics != nil {
+		h.opts.Metrics.ObserveQuery(protocolOf(w))
+	}
+
+	aclRule := ""
+	upstream := h.provider.Name()
+
+	var resp *dns.Msg
+	var err error
+	var cacheHit, dispatched bool
+	if h.opts.ACL != nil {
+		var handled bool
+		resp, handled = h.applyACL(w, r, &aclRule, &upstream, &cacheHit, &dispatched)
+		if !handled {
+			resp, cacheHit, err = h.queryCached(r)
+			dispatched = true
+		}
+	} else {
+		resp, cacheHit, err = h.queryCached(r)
+		dispatched = true
+	}
+
+	if err != nil {
+		glog.Errorf("gdns: upstream query failed: %v", err)
+		resp = new(dns.Msg)
+		resp.SetRcode(r, dns.RcodeServerFailure)
+	}
+
+	if werr := w.WriteMsg(resp); werr != nil {
+		glog.Errorf("gdns: writing response: %v", werr)
+	}
+
+	h.record(w, r, resp, aclRule, upstream, start, cacheHit, dispatched, err)
+}
+
+// record reports the completed query to Metrics/QueryLog, if configured.
+func (h *Handler) record(w dns.ResponseWriter, r, resp *dns.Msg, aclRule, upstream string, start time.Time, cacheHit, dispatched bool, queryErr error) {
+	rtt := time.Since(start)
+
+	size := 0
+	if resp != nil {
+		if wire, err := resp.Pack(); err == nil {
+			size = len(wire)
+		}
+	}
+	rcode := dns.RcodeServerFailure
+	if resp != nil {
+		rcode = resp.Rcode
+	}
+
+	if h.opts.Metrics != nil {
+		// A cache hit or an ACL block/rewrite never contacts an upstream, so
+		// recording an "upstream" sample for it would fabricate a
+		// near-zero-latency, zero-error data point against upstream.
+		if dispatched && !cacheHit {
+			h.opts.Metrics.ObserveUpstream(upstream, rtt, queryErr)
+		}
+		h.opts.Metrics.ObserveResponse(rcode, size)
+	}
+
+	if h.opts.QueryLog != nil && len(r.Question) > 0 {
+		q := r.Question[0]
+		h.opts.QueryLog.Log(metrics.Entry{
+			Time:     start,
+			ClientIP: ipString(clientIP(w)),
+			QName:    q.Name,
+			QType:    dns.TypeToString[q.Qtype],
+			ACLRule:  aclRule,
+			Upstream: upstream,
+			CacheHit: cacheHit,
+			RTTMs:    float64(rtt) / float64(time.Millisecond),
+			Rcode:    dns.RcodeToString[rcode],
+		})
+	}
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// protocolOf reports whether w is writing to a UDP or TCP client.
+func protocolOf(w dns.ResponseWriter) string {
+	switch w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		return "udp"
+	case *net.TCPAddr:
+		return "tcp"
+	default:
+		return "other"
+	}
+}
+
+// applyACL evaluates the configured ACL for r and, if a rule matched,
+// returns the response to write (handled=true), recording the matched
+// rule's action and the upstream actually used into aclRule/upstream.
+// handled=false means no rule matched and the caller should fall through to
+// the default upstream.
+func (h *Handler) applyACL(w dns.ResponseWriter, r *dns.Msg, aclRule, upstream *string, cacheHit, dispatched *bool) (resp *dns.Msg, handled bool) {
+	if len(r.Question) == 0 {
+		return nil, false
+	}
+
+	ci := &acl.ConnInfo{ClientIP: clientIP(w), Question: r.Question[0]}
+	decision := h.opts.ACL.Evaluate(ci, time.Now())
+	if !decision.Matched {
+		return nil, false
+	}
+	*aclRule = string(decision.Rule.Action)
+
+	switch decision.Rule.Action {
+	case acl.ActionBlock:
+		m := new(dns.Msg)
+		if decision.Rule.Refuse {
+			m.SetRcode(r, dns.RcodeRefused)
+		} else {
+			m.SetRcode(r, dns.RcodeNameError)
+		}
+		return m, true
+
+	case acl.ActionRewrite:
+		return rewriteResponse(r, decision.Rule), true
+
+	case acl.ActionRoute:
+		target, ok := h.opts.Upstreams[decision.Rule.Upstream]
+		if !ok {
+			glog.Errorf("gdns: acl route to unknown upstream %q", decision.Rule.Upstream)
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeServerFailure)
+			return m, true
+		}
+		*upstream = target.Name()
+		*dispatched = true
+		resp, err := target.Query(r)
+		if err != nil {
+			glog.Errorf("gdns: acl route query failed: %v", err)
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeServerFailure)
+			return m, true
+		}
+		return resp, true
+
+	case acl.ActionAllow:
+		resp, hit, err := h.queryCached(r)
+		*cacheHit = hit
+		*dispatched = true
+		if err != nil {
+			glog.Errorf("gdns: upstream query failed: %v", err)
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeServerFailure)
+			return m, true
+		}
+		return resp, true
+
+	default:
+		return nil, false
+	}
+}
+
+func rewriteResponse(r *dns.Msg, rule acl.Rule) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	q := r.Question[0]
+	var rr dns.RR
+	var err error
+	switch {
+	case q.Qtype == dns.TypeA && rule.RewriteA != "":
+		rr, err = dns.NewRR(q.Name + " 60 IN A " + rule.RewriteA)
+	case q.Qtype == dns.TypeAAAA && rule.RewriteAAAA != "":
+		rr, err = dns.NewRR(q.Name + " 60 IN AAAA " + rule.RewriteAAAA)
+	case rule.RewriteCNAME != "":
+		rr, err = dns.NewRR(q.Name + " 60 IN CNAME " + rule.RewriteCNAME)
+	}
+	if err == nil && rr != nil {
+		m.Answer = append(m.Answer, rr)
+	}
+	return m
+}
+
+// clientIP extracts the client's IP address from a dns.ResponseWriter.
+func clientIP(w dns.ResponseWriter) net.IP {
+	host, _, err := net.SplitHostPort(w.RemoteAddr().String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// Query resolves r against the Cache (if configured) and the underlying
+// Provider, without touching the network transport. Callers that need to
+// know whether the answer came from cache should use queryCached directly.
+func (h *Handler) Query(r *dns.Msg) (*dns.Msg, error) {
+	resp, _, err := h.queryCached(r)
+	return resp, err
+}
+
+// queryCached is Query plus a hit/miss signal, so Handle's Metrics/QueryLog
+// bookkeeping can tell cached answers apart from ones that actually went
+// upstream. Concurrent identical questions are coalesced via singleflight so
+// a cache-filling query is only ever issued once.
+func (h *Handler) queryCached(r *dns.Msg) (resp *dns.Msg, cacheHit bool, err error) {
+	if h.opts.Cache == nil {
+		resp, err = h.provider.Query(r)
+		return resp, false, err
+	}
+
+	if cached := h.opts.Cache.Get(r); cached != nil {
+		h.observeCache(true)
+		cached.Id = r.Id
+		return cached, true, nil
+	}
+	h.observeCache(false)
+
+	key := cache.SingleflightKey(r)
+	v, err, _ := h.group.Do(key, func() (interface{}, error) {
+		resp, err := h.provider.Query(r)
+		if err != nil {
+			return nil, err
+		}
+		h.opts.Cache.Set(r, resp)
+		return resp, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp = v.(*dns.Msg).Copy()
+	resp.Id = r.Id
+	return resp, false, nil
+}
+
+// observeCache records a cache hit/miss, if Metrics is configured.
+func (h *Handler) observeCache(hit bool) {
+	if h.opts.Metrics != nil {
+		h.opts.Metrics.ObserveCache(hit)
+	}
+}
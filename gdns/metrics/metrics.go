@@ -0,0 +1,109 @@
+// Package metrics exposes Prometheus counters/histograms for gdns.Handler
+// and a structured JSON query logger, replacing the ad-hoc glog.V(LDEBUG)
+// dumps that were the only observability this proxy had before.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Recorder owns the Prometheus collectors for one proxy instance.
+type Recorder struct {
+	queriesTotal    *prometheus.CounterVec
+	upstreamLatency *prometheus.HistogramVec
+	upstreamErrors  *prometheus.CounterVec
+	cacheHits       prometheus.Counter
+	cacheMisses     prometheus.Counter
+	responseCodes   *prometheus.CounterVec
+	responseSize    prometheus.Histogram
+}
+
+// NewRecorder returns a Recorder whose collectors are registered on the
+// default Prometheus registry, which is also what Handler serves.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		queriesTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "gdns_queries_total",
+			Help: "Queries received, by listening protocol.",
+		}, []string{"protocol"}),
+
+		upstreamLatency: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gdns_upstream_latency_seconds",
+			Help:    "Upstream query latency, by provider.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"provider"}),
+
+		upstreamErrors: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "gdns_upstream_errors_total",
+			Help: "Upstream query errors, by provider.",
+		}, []string{"provider"}),
+
+		cacheHits: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "gdns_cache_hits_total",
+			Help: "Cache hits.",
+		}),
+
+		cacheMisses: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "gdns_cache_misses_total",
+			Help: "Cache misses.",
+		}),
+
+		responseCodes: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "gdns_response_codes_total",
+			Help: "Responses sent, by RCODE.",
+		}, []string{"rcode"}),
+
+		responseSize: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "gdns_response_size_bytes",
+			Help:    "Size of responses sent to clients.",
+			Buckets: prometheus.ExponentialBuckets(64, 2, 10),
+		}),
+	}
+}
+
+// ObserveQuery records one received query on protocol ("udp"/"tcp"/"other").
+func (r *Recorder) ObserveQuery(protocol string) {
+	r.queriesTotal.WithLabelValues(protocol).Inc()
+}
+
+// ObserveUpstream records the latency (or error) of a query to provider.
+func (r *Recorder) ObserveUpstream(provider string, d time.Duration, err error) {
+	if err != nil {
+		r.upstreamErrors.WithLabelValues(provider).Inc()
+		return
+	}
+	r.upstreamLatency.WithLabelValues(provider).Observe(d.Seconds())
+}
+
+// ObserveCache records a cache hit or miss.
+func (r *Recorder) ObserveCache(hit bool) {
+	if hit {
+		r.cacheHits.Inc()
+	} else {
+		r.cacheMisses.Inc()
+	}
+}
+
+// ObserveResponse records the RCODE and wire size of a response sent to a
+// client.
+func (r *Recorder) ObserveResponse(rcode int, size int) {
+	r.responseCodes.WithLabelValues(prometheusRcode(rcode)).Inc()
+	r.responseSize.Observe(float64(size))
+}
+
+// Handler returns the /metrics HTTP handler.
+func (r *Recorder) Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ListenAndServe runs the /metrics endpoint on addr until the process exits.
+func (r *Recorder) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.Handler())
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultMaxBytes is the size at which QueryLogger rotates its file if the
+// caller doesn't specify one.
+const defaultMaxBytes = 100 * 1024 * 1024 // 100MB
+
+// Entry is one structured, append-only query log record.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	ClientIP string    `json:"client_ip"`
+	QName    string    `json:"qname"`
+	QType    string    `json:"qtype"`
+	ACLRule  string    `json:"acl_rule,omitempty"`
+	Upstream string    `json:"upstream,omitempty"`
+	CacheHit bool      `json:"cache_hit"`
+	RTTMs    float64   `json:"rtt_ms"`
+	Rcode    string    `json:"rcode"`
+}
+
+// QueryLogger appends one JSON line per query to a file, rotating it (by
+// renaming the current file aside) before a line would take it past
+// maxBytes.
+type QueryLogger struct {
+	path     string
+	maxBytes int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewQueryLogger opens (creating if needed) path for appending. maxBytes<=0
+// uses defaultMaxBytes.
+func NewQueryLogger(path string, maxBytes int64) (*QueryLogger, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	q := &QueryLogger{path: path, maxBytes: maxBytes}
+	if err := q.open(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *QueryLogger) open() error {
+	f, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("metrics: opening query log %s: %v", q.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	q.f = f
+	q.size = info.Size()
+	return nil
+}
+
+// Log appends e as one JSON line, rotating the file first if appending the
+// line would take it past maxBytes.
+func (q *QueryLogger) Log(e Entry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.size+int64(len(line)) > q.maxBytes {
+		if err := q.rotate(); err != nil {
+			return
+		}
+	}
+
+	n, err := q.f.Write(line)
+	if err == nil {
+		q.size += int64(n)
+	}
+}
+
+// rotate renames the current log file aside (path + ".1") and opens a fresh
+// one. Callers must hold q.mu.
+func (q *QueryLogger) rotate() error {
+	q.f.Close()
+	os.Rename(q.path, q.path+".1")
+	return q.open()
+}
+
+// Close closes the underlying file.
+func (q *QueryLogger) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.f.Close()
+}
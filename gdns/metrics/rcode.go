@@ -0,0 +1,10 @@
+package metrics
+
+import "github.com/miekg/dns"
+
+func prometheusRcode(rcode int) string {
+	if name, ok := dns.RcodeToString[rcode]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
@@ -0,0 +1,64 @@
+package gdns
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/chenhw2/google-https-dns/gdns/bootstrap"
+)
+
+// Endpoint is a resolved network endpoint (host IP plus port) used to reach
+// either a bootstrap DNS server or a DoH/DoT upstream.
+type Endpoint struct {
+	IP   net.IP
+	Port int
+}
+
+// ParseEndpoint parses a "host[:port]" string into an Endpoint, resolving
+// host if it is not already a literal IP. defaultPort is used when the
+// string does not specify one.
+func ParseEndpoint(s string, defaultPort int) (Endpoint, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		host = s
+		port = ""
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return Endpoint{}, err
+		}
+		ip = ips[0]
+	}
+
+	p := defaultPort
+	if port != "" {
+		if _, err := fmt.Sscan(port, &p); err != nil {
+			return Endpoint{}, err
+		}
+	}
+
+	return Endpoint{IP: ip, Port: p}, nil
+}
+
+// GDNSOptions carries the knobs passed to every Provider constructor: how
+// to dial out (custom endpoint IPs, bootstrap DNS servers) and how to shape
+// the outgoing request (EDNS, padding, TLS verification). Each Provider
+// reads only the fields it uses; PROXY is stored but no Provider dials
+// through it.
+type GDNSOptions struct {
+	PROXY  string
+	EDNS   string
+	Pad    bool
+	Secure bool
+
+	EndpointIPs []net.IP
+	DNSServers  []Endpoint
+
+	// Bootstrap, if set, supersedes EndpointIPs: GDNSProvider dials
+	// whichever candidate Bootstrap.Best() currently reports instead of a
+	// static list.
+	Bootstrap *bootstrap.Pool
+}
@@ -0,0 +1,48 @@
+package gdns
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// PlainProvider forwards queries to a plain (non-encrypted) DNS server,
+// e.g. as the backend for --serve-doh's reverse DoH mode.
+type PlainProvider struct {
+	addr    string
+	client  *dns.Client
+	tcpOnly *dns.Client
+}
+
+// NewPlainProvider builds a Provider that forwards queries to addr
+// (host:port) over plain UDP, re-querying over TCP if the UDP answer comes
+// back truncated (dns.Client.Exchange does not do this automatically).
+func NewPlainProvider(addr string) *PlainProvider {
+	return &PlainProvider{
+		addr:    addr,
+		client:  &dns.Client{Timeout: 5 * time.Second},
+		tcpOnly: &dns.Client{Net: "tcp", Timeout: 5 * time.Second},
+	}
+}
+
+// Name implements Provider.
+func (p *PlainProvider) Name() string { return "dns://" + p.addr }
+
+// Query implements Provider.
+func (p *PlainProvider) Query(req *dns.Msg) (*dns.Msg, error) {
+	resp, _, err := p.client.Exchange(req, p.addr)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Truncated {
+		return p.queryTCP(req)
+	}
+	return resp, nil
+}
+
+// queryTCP re-sends req over TCP, used when the UDP answer came back
+// truncated.
+func (p *PlainProvider) queryTCP(req *dns.Msg) (*dns.Msg, error) {
+	resp, _, err := p.tcpOnly.Exchange(req, p.addr)
+	return resp, err
+}
@@ -0,0 +1,16 @@
+package gdns
+
+import "github.com/miekg/dns"
+
+// Provider resolves a single DNS query against some upstream transport
+// (Google's JSON DoH API, RFC 8484 DoH, DoT, plain DNS, ...). Implementations
+// must be safe for concurrent use.
+type Provider interface {
+	// Name identifies the provider for logging and for acl.ActionRoute
+	// rules (acl.Rule.Upstream), e.g. "google", "cloudflare",
+	// "tls://dns.google:853".
+	Name() string
+
+	// Query sends req upstream and returns the upstream's answer.
+	Query(req *dns.Msg) (*dns.Msg, error)
+}
@@ -0,0 +1,99 @@
+package gdns
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// RouterMode selects how a Router with multiple upstreams picks an answer.
+type RouterMode string
+
+const (
+	// ModeRace sends the query to every upstream concurrently and returns
+	// the first valid (non-error) answer.
+	ModeRace RouterMode = "race"
+	// ModeRoundRobin sends each query to the next upstream in sequence.
+	ModeRoundRobin RouterMode = "rr"
+	// ModeFailover always tries upstreams in order, falling through to the
+	// next one only when the previous one errors.
+	ModeFailover RouterMode = "failover"
+)
+
+// Router is a Provider that fans a query out to one or more underlying
+// Providers according to Mode.
+type Router struct {
+	Mode      RouterMode
+	upstreams []Provider
+	next      uint32
+}
+
+// NewRouter builds a Router over upstreams using mode. At least one upstream
+// is required.
+func NewRouter(mode RouterMode, upstreams ...Provider) (*Router, error) {
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("gdns: router needs at least one upstream")
+	}
+	return &Router{Mode: mode, upstreams: upstreams}, nil
+}
+
+// Name implements Provider.
+func (r *Router) Name() string { return "router(" + string(r.Mode) + ")" }
+
+// Query implements Provider, dispatching according to r.Mode.
+func (r *Router) Query(req *dns.Msg) (*dns.Msg, error) {
+	switch r.Mode {
+	case ModeRoundRobin:
+		idx := atomic.AddUint32(&r.next, 1) - 1
+		return r.upstreams[idx%uint32(len(r.upstreams))].Query(req)
+
+	case ModeRace:
+		return r.race(req)
+
+	case ModeFailover:
+		fallthrough
+	default:
+		var lastErr error
+		for _, u := range r.upstreams {
+			resp, err := u.Query(req)
+			if err == nil {
+				return resp, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
+
+func (r *Router) race(req *dns.Msg) (*dns.Msg, error) {
+	type result struct {
+		resp *dns.Msg
+		err  error
+	}
+
+	results := make(chan result, len(r.upstreams))
+	var wg sync.WaitGroup
+	for _, u := range r.upstreams {
+		wg.Add(1)
+		go func(u Provider) {
+			defer wg.Done()
+			resp, err := u.Query(req)
+			results <- result{resp, err}
+		}(u)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var lastErr error
+	for res := range results {
+		if res.err == nil {
+			return res.resp, nil
+		}
+		lastErr = res.err
+	}
+	return nil, lastErr
+}
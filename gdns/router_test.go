@@ -0,0 +1,133 @@
+package gdns
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// fakeProvider answers with name, or fails if err is set.
+type fakeProvider struct {
+	name string
+	err  error
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Query(req *dns.Msg) (*dns.Msg, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Extra = []dns.RR{} // distinguishes a real response from the zero value
+	resp.Id = req.Id
+	resp.Answer = []dns.RR{mustRouterRR(p.name)}
+	return resp, nil
+}
+
+func mustRouterRR(owner string) dns.RR {
+	rr, err := dns.NewRR(fmt.Sprintf("example.com. 60 IN TXT %q", owner))
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+func newRouterQuery() *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	return req
+}
+
+func TestRouterRoundRobinCyclesUpstreams(t *testing.T) {
+	a, b := &fakeProvider{name: "a"}, &fakeProvider{name: "b"}
+	r, err := NewRouter(ModeRoundRobin, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		resp, err := r.Query(newRouterQuery())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, resp.Answer[0].(*dns.TXT).Txt[0])
+	}
+
+	want := []string{"a", "b", "a", "b"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("query %d: got upstream %q, want %q (sequence: %v)", i, got[i], want[i], got)
+		}
+	}
+}
+
+func TestRouterFailoverFallsThroughOnError(t *testing.T) {
+	bad := &fakeProvider{name: "bad", err: fmt.Errorf("boom")}
+	good := &fakeProvider{name: "good"}
+	r, err := NewRouter(ModeFailover, bad, good)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := r.Query(newRouterQuery())
+	if err != nil {
+		t.Fatalf("expected failover to the working upstream, got error: %v", err)
+	}
+	if got := resp.Answer[0].(*dns.TXT).Txt[0]; got != "good" {
+		t.Errorf("answer came from %q, want \"good\"", got)
+	}
+}
+
+func TestRouterFailoverReturnsLastErrorWhenAllFail(t *testing.T) {
+	a := &fakeProvider{name: "a", err: fmt.Errorf("a failed")}
+	b := &fakeProvider{name: "b", err: fmt.Errorf("b failed")}
+	r, err := NewRouter(ModeFailover, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.Query(newRouterQuery())
+	if err == nil || err.Error() != "b failed" {
+		t.Errorf("err = %v, want the last upstream's error", err)
+	}
+}
+
+func TestRouterRaceReturnsAWorkingAnswer(t *testing.T) {
+	bad := &fakeProvider{name: "bad", err: fmt.Errorf("boom")}
+	good := &fakeProvider{name: "good"}
+	r, err := NewRouter(ModeRace, bad, good)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := r.Query(newRouterQuery())
+	if err != nil {
+		t.Fatalf("expected the race to surface the working answer, got error: %v", err)
+	}
+	if got := resp.Answer[0].(*dns.TXT).Txt[0]; got != "good" {
+		t.Errorf("answer came from %q, want \"good\"", got)
+	}
+}
+
+func TestRouterRaceReturnsErrorWhenAllFail(t *testing.T) {
+	a := &fakeProvider{name: "a", err: fmt.Errorf("a failed")}
+	b := &fakeProvider{name: "b", err: fmt.Errorf("b failed")}
+	r, err := NewRouter(ModeRace, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Query(newRouterQuery()); err == nil {
+		t.Error("expected an error when every upstream fails")
+	}
+}
+
+func TestNewRouterRequiresAtLeastOneUpstream(t *testing.T) {
+	if _, err := NewRouter(ModeFailover); err == nil {
+		t.Error("expected an error constructing a Router with no upstreams")
+	}
+}
@@ -0,0 +1,55 @@
+package gdns
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/chenhw2/google-https-dns/gdns/doq"
+)
+
+// NewProvider builds a Provider from a single --upstream spec. Recognised
+// schemes:
+//
+//	https://host/path        RFC 8484 application/dns-message DoH
+//	gdns://host/path          Google-style application/dns-json DoH
+//	cloudflare://[host/path]  Cloudflare-style application/dns-json DoH
+//	tls://host:port           DNS-over-TLS
+//	quic://host:port          DNS-over-QUIC (RFC 9250)
+func NewProvider(spec string, opts *GDNSOptions) (Provider, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("gdns: invalid upstream spec %q: %v", spec, err)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "gdns":
+		return NewGDNSProvider("https://"+u.Host+u.Path, opts)
+	case "cloudflare":
+		if u.Host == "" {
+			return NewCloudflareProvider("", opts)
+		}
+		return NewCloudflareProvider("https://"+u.Host+u.Path, opts)
+	case "tls":
+		return NewDoTProvider(u.Host, opts)
+	case "quic":
+		return doq.NewProvider(u.Host, !opts.Secure), nil
+	case "https":
+		return NewDoHMsgProvider(spec, opts)
+	default:
+		return nil, fmt.Errorf("gdns: unsupported upstream scheme %q", u.Scheme)
+	}
+}
+
+// NewProviders builds a Provider for every spec in specs.
+func NewProviders(specs []string, opts *GDNSOptions) ([]Provider, error) {
+	providers := make([]Provider, 0, len(specs))
+	for _, spec := range specs {
+		p, err := NewProvider(spec, opts)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
@@ -1,20 +1,33 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"math/rand"
 	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/chenhw2/google-https-dns/gdns"
+	"github.com/chenhw2/google-https-dns/gdns/acl"
+	"github.com/chenhw2/google-https-dns/gdns/bootstrap"
+	"github.com/chenhw2/google-https-dns/gdns/cache"
+	"github.com/chenhw2/google-https-dns/gdns/dohserver"
+	"github.com/chenhw2/google-https-dns/gdns/doq"
+	"github.com/chenhw2/google-https-dns/gdns/metrics"
 	"github.com/golang/glog"
 	"github.com/miekg/dns"
 	"github.com/urfave/cli"
 )
 
+// blocklistPriority is the Priority given to rules generated from
+// --blocklist-file: low enough that any explicit --acl-file rule (allow,
+// rewrite, or a block of its own) is evaluated first and can override it.
+const blocklistPriority = 1 << 20
+
 var (
 	version = "MISSING build version [git hash]"
 
@@ -23,8 +36,107 @@ var (
 
 	listenAddress   string
 	listenProtocols []string
+
+	upstreamSpecs []string
+	upstreamMode  string
+
+	cacheSize   int
+	cacheMinTTL int
+	cacheMaxTTL int
+	negTTLCap   int
+
+	serveDoHAddr string
+	serveDoHCert string
+	serveDoHKey  string
+	backendDNS   string
+
+	aclFile        string
+	blocklistFiles []string
+
+	quicAddr string
+	quicCert string
+	quicKey  string
+
+	bootstrapInterval time.Duration
+
+	metricsAddr  string
+	querylogFile string
 )
 
+// startBootstrap resolves the --endpoint hostname through a gdns/bootstrap
+// Resolver, wiring the resulting health-checked Pool into gdnsOPT so
+// GDNSProvider dials whichever candidate IP currently looks best.
+func startBootstrap() error {
+	u, err := url.Parse(gdnsEndPT)
+	if err != nil {
+		return err
+	}
+
+	var servers []string
+	for _, d := range gdnsOPT.DNSServers {
+		servers = append(servers, net.JoinHostPort(d.IP.String(), fmt.Sprintf("%d", d.Port)))
+	}
+
+	pool := bootstrap.NewPool()
+	r := &bootstrap.Resolver{
+		Hostname:         u.Hostname(),
+		BootstrapServers: servers,
+		Interval:         bootstrapInterval,
+		Pool:             pool,
+	}
+	if err := r.Start(); err != nil {
+		return err
+	}
+
+	gdnsOPT.Bootstrap = pool
+	return nil
+}
+
+// buildProviders assembles the legacy --endpoint Google provider plus any
+// --upstream specs, returning both the full list (for the default
+// provider/router) and a name-indexed map (for acl.ActionRoute rules).
+func buildProviders() (providers []gdns.Provider, byName map[string]gdns.Provider, err error) {
+	google, err := gdns.NewGDNSProvider(gdnsEndPT, &gdnsOPT)
+	if err != nil {
+		return nil, nil, err
+	}
+	providers = append(providers, google)
+
+	extra, err := gdns.NewProviders(upstreamSpecs, &gdnsOPT)
+	if err != nil {
+		return nil, nil, err
+	}
+	providers = append(providers, extra...)
+
+	byName = make(map[string]gdns.Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+
+	return providers, byName, nil
+}
+
+// buildProvider combines providers into a single gdns.Provider, wrapping
+// them in a gdns.Router when there is more than one.
+func buildProvider(providers []gdns.Provider) (gdns.Provider, error) {
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+	return gdns.NewRouter(gdns.RouterMode(upstreamMode), providers...)
+}
+
+// dumpCacheStatsOnSIGUSR1 logs the cache's hit/miss counters to glog every
+// time the process receives SIGUSR1, e.g. `kill -USR1 $(pidof ...)`.
+func dumpCacheStatsOnSIGUSR1(c *cache.Cache) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	go func() {
+		for range sig {
+			glog.V(LINFO).Infof("cache stats: %+v", c.Stats())
+		}
+	}()
+}
+
 func serve(net, addr string) {
 	glog.V(LINFO).Infof("starting %s service on %s", net, addr)
 
@@ -103,6 +215,82 @@ func main() {
 			Name:  "tcp, T",
 			Usage: "Listen on TCP",
 		},
+		cli.StringSliceFlag{
+			Name:  "upstream, u",
+			Usage: "Additional upstream resolver spec(s), e.g. https://1.1.1.1/dns-query, tls://dns.google:853, gdns://dns.google.com/resolve; repeatable",
+		},
+		cli.StringFlag{
+			Name:  "upstream-mode",
+			Value: "failover",
+			Usage: "How to combine multiple upstreams: race, rr, or failover",
+		},
+		cli.IntFlag{
+			Name:  "cache-size",
+			Value: 10000,
+			Usage: "Max number of cached answers; 0 disables the response cache",
+		},
+		cli.IntFlag{
+			Name:  "cache-min-ttl",
+			Usage: "Minimum TTL (seconds) applied to cached positive answers",
+		},
+		cli.IntFlag{
+			Name:  "cache-max-ttl",
+			Usage: "Maximum TTL (seconds) applied to cached positive answers; 0 means no cap",
+		},
+		cli.IntFlag{
+			Name:  "neg-ttl-cap",
+			Value: 3600,
+			Usage: "Maximum TTL (seconds) applied to negative (NXDOMAIN/NODATA) answers",
+		},
+		cli.StringFlag{
+			Name:  "serve-doh",
+			Usage: "Also run a DoH server (Google /resolve and RFC 8484 /dns-query) on this address, e.g. :8443",
+		},
+		cli.StringFlag{
+			Name:  "serve-doh-cert",
+			Usage: "TLS certificate file for --serve-doh",
+		},
+		cli.StringFlag{
+			Name:  "serve-doh-key",
+			Usage: "TLS key file for --serve-doh",
+		},
+		cli.StringFlag{
+			Name:  "backend-dns",
+			Usage: "Plain DNS server (host:port) to use as the --serve-doh backend instead of the configured upstream(s)",
+		},
+		cli.StringFlag{
+			Name:  "acl-file",
+			Usage: "YAML or JSON file of ordered ACL rules (block/rewrite/route/allow); see gdns/acl",
+		},
+		cli.StringSliceFlag{
+			Name:  "blocklist-file",
+			Usage: "Hosts/Pi-hole/AdGuard format hostlist file to block, e.g. for ad-blocking; repeatable",
+		},
+		cli.StringFlag{
+			Name:  "quic",
+			Usage: "Also run a DNS-over-QUIC (RFC 9250) listener on this address, e.g. :853",
+		},
+		cli.StringFlag{
+			Name:  "quic-cert",
+			Usage: "TLS certificate file for --quic",
+		},
+		cli.StringFlag{
+			Name:  "quic-key",
+			Usage: "TLS key file for --quic",
+		},
+		cli.DurationFlag{
+			Name:  "bootstrap-interval",
+			Value: 5 * time.Minute,
+			Usage: "How often to re-resolve and health-check the DoH endpoint's IPs; 0 disables the bootstrap pool",
+		},
+		cli.StringFlag{
+			Name:  "metrics-addr",
+			Usage: "Serve Prometheus metrics on this address, e.g. :9153",
+		},
+		cli.StringFlag{
+			Name:  "querylog-file",
+			Usage: "Append a structured JSON query log to this file, rotating it as it grows",
+		},
 	}
 	app.Action = func(c *cli.Context) error {
 		glogGangstaShim(c)
@@ -114,7 +302,9 @@ func main() {
 		if c.Bool("udp") {
 			listenProtocols = append(listenProtocols, "udp")
 		}
-		if 0 == len(listenProtocols) {
+		serveDoHAddr = c.String("serve-doh")
+		quicAddr = c.String("quic")
+		if 0 == len(listenProtocols) && serveDoHAddr == "" && quicAddr == "" {
 			cli.ShowAppHelp(c)
 			os.Exit(0)
 		}
@@ -142,33 +332,163 @@ func main() {
 		}
 		glog.V(LDEBUG).Infof("DNSServers%+v", gdnsOPT.DNSServers)
 
+		upstreamSpecs = c.StringSlice("upstream")
+		upstreamMode = c.String("upstream-mode")
+
+		cacheSize = c.Int("cache-size")
+		cacheMinTTL = c.Int("cache-min-ttl")
+		cacheMaxTTL = c.Int("cache-max-ttl")
+		negTTLCap = c.Int("neg-ttl-cap")
+
+		serveDoHCert = c.String("serve-doh-cert")
+		serveDoHKey = c.String("serve-doh-key")
+		backendDNS = c.String("backend-dns")
+
+		aclFile = c.String("acl-file")
+		blocklistFiles = c.StringSlice("blocklist-file")
+
+		quicCert = c.String("quic-cert")
+		quicKey = c.String("quic-key")
+
+		bootstrapInterval = c.Duration("bootstrap-interval")
+
+		metricsAddr = c.String("metrics-addr")
+		querylogFile = c.String("querylog-file")
+
 		return nil
 	}
 	app.Flags = append(app.Flags, glogGangstaFlags...)
 	app.Run(os.Args)
 	defer glog.Flush()
 
-	provider, err := gdns.NewGDNSProvider(gdnsEndPT, &gdnsOPT)
+	if bootstrapInterval > 0 && len(gdnsOPT.EndpointIPs) == 0 {
+		if err := startBootstrap(); err != nil {
+			glog.Exitln(err)
+		}
+	}
+
+	providers, upstreamsByName, err := buildProviders()
 	if err != nil {
 		glog.Exitln(err)
 	}
-	// options := &gdns.HandlerOptions{}
-	handler := gdns.NewHandler(provider, new(gdns.HandlerOptions))
+	provider, err := buildProvider(providers)
+	if err != nil {
+		glog.Exitln(err)
+	}
+
+	handlerOpts := &gdns.HandlerOptions{Upstreams: upstreamsByName}
+
+	var aclRules []acl.Rule
+	if aclFile != "" {
+		rules, err := acl.LoadRules(aclFile)
+		if err != nil {
+			glog.Exitln(err)
+		}
+		aclRules = append(aclRules, rules...)
+	}
+	for _, path := range blocklistFiles {
+		rules, err := acl.LoadBlocklist(path, blocklistPriority)
+		if err != nil {
+			glog.Exitln(err)
+		}
+		aclRules = append(aclRules, rules...)
+	}
+	if len(aclRules) > 0 {
+		engine, err := acl.New(aclRules)
+		if err != nil {
+			glog.Exitln(err)
+		}
+		handlerOpts.ACL = engine
+	}
+
+	var recorder *metrics.Recorder
+	if metricsAddr != "" {
+		recorder = metrics.NewRecorder()
+		go func() {
+			if err := recorder.ListenAndServe(metricsAddr); err != nil {
+				glog.Errorf("metrics server exited: %v", err)
+			}
+		}()
+	}
+	handlerOpts.Metrics = recorder
+
+	if querylogFile != "" {
+		ql, err := metrics.NewQueryLogger(querylogFile, 0)
+		if err != nil {
+			glog.Exitln(err)
+		}
+		defer ql.Close()
+		handlerOpts.QueryLog = ql
+	}
+
+	if cacheSize > 0 {
+		c := cache.New(cache.Options{
+			MaxEntries: cacheSize,
+			MinTTL:     uint32(cacheMinTTL),
+			MaxTTL:     uint32(cacheMaxTTL),
+			NegTTLCap:  uint32(negTTLCap),
+		})
+		dumpCacheStatsOnSIGUSR1(c)
+		handlerOpts.Cache = c
+	}
+
+	handler := gdns.NewHandler(provider, handlerOpts)
 	dns.HandleFunc(".", handler.Handle)
 
-	// start the servers
 	servers := make(chan bool)
+	running := 0
+
+	if serveDoHAddr != "" {
+		backend := handler
+		if backendDNS != "" {
+			backend = gdns.NewHandler(gdns.NewPlainProvider(backendDNS), handlerOpts)
+		}
+		srv := dohserver.New(backend)
+		running++
+		go func() {
+			if err := srv.ListenAndServeTLS(serveDoHAddr, serveDoHCert, serveDoHKey); err != nil {
+				glog.Errorf("doh server exited: %v", err)
+			}
+			servers <- true
+		}()
+	}
+
+	if quicAddr != "" {
+		tlsConfig, err := quicTLSConfig(quicCert, quicKey)
+		if err != nil {
+			glog.Exitln(err)
+		}
+		running++
+		go func() {
+			if err := doq.ListenAndServe(quicAddr, tlsConfig, dns.DefaultServeMux); err != nil {
+				glog.Errorf("quic server exited: %v", err)
+			}
+			servers <- true
+		}()
+	}
+
+	// start the udp/tcp servers
 	for _, protocol := range listenProtocols {
+		running++
 		go func(protocol string) {
 			serve(protocol, listenAddress)
 			servers <- true
 		}(protocol)
 	}
 
-	// wait for servers to exit
-	for range listenProtocols {
+	// wait for all servers to exit
+	for i := 0; i < running; i++ {
 		<-servers
 	}
 
 	glog.V(LINFO).Infoln("servers exited, stopping")
 }
+
+// quicTLSConfig loads the certificate used by the DoQ listener.
+func quicTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("quic: loading cert/key: %v", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}